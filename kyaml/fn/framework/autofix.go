@@ -0,0 +1,345 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// AutofixCommand wraps inner, a validator ResourceListProcessor, and adds an
+// --autofix flag to the returned Command. Items whose Severity is Fix have
+// their Patch applied unconditionally; with --autofix set, any Item
+// carrying a Patch is applied regardless of severity. inner is re-run after
+// applying fixes so ResourceList.results reports only what's still
+// unfixable -- letting a single validator function also serve as a mutator
+// without being rewritten as one.
+func AutofixCommand(functionConfig interface{}, inner ResourceListProcessor) *cobra.Command {
+	var autofix bool
+	cmd := Command(functionConfig, func(items []*yaml.RNode) ([]*yaml.RNode, error) {
+		return runAutofix(inner, items, autofix)
+	})
+	cmd.Flags().BoolVar(&autofix, "autofix", false, "apply every result's patch, not just Fix-severity ones")
+	return cmd
+}
+
+func runAutofix(inner ResourceListProcessor, items []*yaml.RNode, autofix bool) ([]*yaml.RNode, error) {
+	items, result, err := runValidator(inner, items)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	fixedAny := false
+	for _, it := range result.Items {
+		if it.Patch == nil || (it.Severity != Fix && !autofix) {
+			continue
+		}
+		if err := applyItemPatch(items, it); err != nil {
+			return nil, errors.Wrap(err)
+		}
+		fixedAny = true
+	}
+	if !fixedAny {
+		return items, result
+	}
+
+	// re-run to confirm the applied patches cleared their findings
+	items, result, err = runValidator(inner, items)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return items, result
+}
+
+func runValidator(fn ResourceListProcessor, items []*yaml.RNode) ([]*yaml.RNode, Result, error) {
+	newItems, err := fn(items)
+	if r, ok := err.(Result); ok {
+		return newItems, r, nil
+	}
+	if err != nil {
+		return nil, Result{}, err
+	}
+	return newItems, Result{}, nil
+}
+
+// applyItemPatch applies it.Patch to the item in items matching
+// it.ResourceRef, in place.
+func applyItemPatch(items []*yaml.RNode, it Item) error {
+	for _, item := range items {
+		meta, err := item.GetMeta()
+		if err != nil {
+			return err
+		}
+		if meta.APIVersion != it.ResourceRef.APIVersion || meta.Kind != it.ResourceRef.Kind ||
+			meta.Namespace != it.ResourceRef.Namespace || meta.Name != it.ResourceRef.Name {
+			continue
+		}
+		return patchNodeInPlace(item, *it.Patch)
+	}
+	return errors.Errorf("autofix: no item matches resourceRef %s %s/%s", it.ResourceRef.APIVersion, it.ResourceRef.Kind, it.ResourceRef.Name)
+}
+
+// patchNodeInPlace applies patch to node's content by computing the patched
+// document as a map[string]interface{} (the same map/list machinery
+// mergeMaps and mergeList already use elsewhere in this package, rather
+// than pulling in a JSON patch library for what's a small, well-specified
+// algorithm) and then reconciling node's fields against it via
+// applyMapToRNode, instead of replacing node wholesale -- so any comments
+// and field order on parts of the document the patch didn't touch survive.
+func patchNodeInPlace(node *yaml.RNode, patch Patch) error {
+	doc, err := toMap(node)
+	if err != nil {
+		return err
+	}
+
+	var patched map[string]interface{}
+	switch patch.Type {
+	case JSONMergePatchType:
+		var mergePatch map[string]interface{}
+		if err := json.Unmarshal([]byte(patch.Patch), &mergePatch); err != nil {
+			return errors.Wrap(err)
+		}
+		patched = applyJSONMergePatch(doc, mergePatch)
+	default:
+		result, err := applyJSONPatch(doc, []byte(patch.Patch))
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		m, ok := result.(map[string]interface{})
+		if !ok {
+			return errors.Errorf("json patch produced a non-object document")
+		}
+		patched = m
+	}
+
+	return applyMapToRNode(node, patched)
+}
+
+// applyJSONMergePatch applies patch to doc following RFC 7396: a key set to
+// null is removed, a key whose value is an object in both doc and patch is
+// merged recursively, and any other key is replaced wholesale.
+func applyJSONMergePatch(doc, patch map[string]interface{}) map[string]interface{} {
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		patchChild, isPatchObject := v.(map[string]interface{})
+		docChild, isDocObject := doc[k].(map[string]interface{})
+		if isPatchObject && isDocObject {
+			doc[k] = applyJSONMergePatch(docChild, patchChild)
+			continue
+		}
+		doc[k] = v
+	}
+	return doc
+}
+
+// jsonPatchOp is one operation of an RFC 6902 JSON Patch document.
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// applyJSONPatch applies the RFC 6902 JSON Patch encoded in raw to doc,
+// navigating doc's generic map[string]interface{}/[]interface{} tree by
+// JSON pointer rather than going through a third-party patch library.
+func applyJSONPatch(doc interface{}, raw []byte) (interface{}, error) {
+	var ops []jsonPatchOp
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	for _, op := range ops {
+		var err error
+		switch op.Op {
+		case "add":
+			doc, err = jsonPointerSet(doc, op.Path, op.Value, true)
+		case "replace":
+			doc, err = jsonPointerSet(doc, op.Path, op.Value, false)
+		case "remove":
+			doc, err = jsonPointerRemove(doc, op.Path)
+		case "copy":
+			var v interface{}
+			if v, err = jsonPointerGet(doc, op.From); err == nil {
+				doc, err = jsonPointerSet(doc, op.Path, v, true)
+			}
+		case "move":
+			var v interface{}
+			if v, err = jsonPointerGet(doc, op.From); err == nil {
+				if doc, err = jsonPointerRemove(doc, op.From); err == nil {
+					doc, err = jsonPointerSet(doc, op.Path, v, true)
+				}
+			}
+		case "test":
+			var v interface{}
+			if v, err = jsonPointerGet(doc, op.Path); err == nil {
+				b1, _ := json.Marshal(v)
+				b2, _ := json.Marshal(op.Value)
+				if string(b1) != string(b2) {
+					err = errors.Errorf("json patch test failed at %q", op.Path)
+				}
+			}
+		default:
+			err = errors.Errorf("unsupported json patch operation %q", op.Op)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// jsonPointerSegments splits an RFC 6901 JSON pointer into its unescaped
+// reference tokens.
+func jsonPointerSegments(path string) []string {
+	if path == "" {
+		return nil
+	}
+	segs := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, s := range segs {
+		s = strings.ReplaceAll(s, "~1", "/")
+		s = strings.ReplaceAll(s, "~0", "~")
+		segs[i] = s
+	}
+	return segs
+}
+
+func jsonPointerGet(doc interface{}, path string) (interface{}, error) {
+	cur := doc
+	for _, seg := range jsonPointerSegments(path) {
+		switch v := cur.(type) {
+		case map[string]interface{}:
+			cur = v[seg]
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, errors.Errorf("invalid json patch array index %q", seg)
+			}
+			cur = v[i]
+		default:
+			return nil, errors.Errorf("cannot navigate into %T at %q", cur, seg)
+		}
+	}
+	return cur, nil
+}
+
+func jsonPointerSet(doc interface{}, path string, value interface{}, insert bool) (interface{}, error) {
+	segs := jsonPointerSegments(path)
+	if len(segs) == 0 {
+		return value, nil
+	}
+	return setAtSegments(doc, segs, value, insert)
+}
+
+func setAtSegments(doc interface{}, segs []string, value interface{}, insert bool) (interface{}, error) {
+	seg := segs[0]
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if len(segs) == 1 {
+			v[seg] = value
+			return v, nil
+		}
+		child, ok := v[seg]
+		if !ok {
+			child = map[string]interface{}{}
+		}
+		updated, err := setAtSegments(child, segs[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		v[seg] = updated
+		return v, nil
+	case []interface{}:
+		if seg == "-" {
+			if len(segs) != 1 {
+				return nil, errors.Errorf("cannot navigate past array append token \"-\"")
+			}
+			return append(v, value), nil
+		}
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i > len(v) {
+			return nil, errors.Errorf("invalid json patch array index %q", seg)
+		}
+		if len(segs) == 1 {
+			if !insert {
+				if i == len(v) {
+					return nil, errors.Errorf("array index %d out of range", i)
+				}
+				v[i] = value
+				return v, nil
+			}
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+			return v, nil
+		}
+		if i >= len(v) {
+			return nil, errors.Errorf("array index %d out of range", i)
+		}
+		updated, err := setAtSegments(v[i], segs[1:], value, insert)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = updated
+		return v, nil
+	default:
+		return nil, errors.Errorf("cannot set field %q on %T", seg, doc)
+	}
+}
+
+func jsonPointerRemove(doc interface{}, path string) (interface{}, error) {
+	segs := jsonPointerSegments(path)
+	if len(segs) == 0 {
+		return nil, errors.Errorf("cannot remove the document root")
+	}
+	return removeAtSegments(doc, segs)
+}
+
+func removeAtSegments(doc interface{}, segs []string) (interface{}, error) {
+	seg := segs[0]
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		if len(segs) == 1 {
+			delete(v, seg)
+			return v, nil
+		}
+		child, ok := v[seg]
+		if !ok {
+			return v, nil
+		}
+		updated, err := removeAtSegments(child, segs[1:])
+		if err != nil {
+			return nil, err
+		}
+		v[seg] = updated
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, errors.Errorf("invalid json patch array index %q", seg)
+		}
+		if len(segs) == 1 {
+			return append(v[:i], v[i+1:]...), nil
+		}
+		updated, err := removeAtSegments(v[i], segs[1:])
+		if err != nil {
+			return nil, err
+		}
+		v[i] = updated
+		return v, nil
+	default:
+		return nil, errors.Errorf("cannot remove field %q from %T", seg, doc)
+	}
+}
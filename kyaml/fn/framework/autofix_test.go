@@ -0,0 +1,284 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestApplyJSONPatch_Ops covers the core RFC 6902 operations: add, replace,
+// remove, move, copy, and a passing test op.
+func TestApplyJSONPatch_Ops(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  map[string]interface{}
+		ops  string
+		want map[string]interface{}
+	}{
+		{
+			"add new field",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "add", "path": "/b", "value": "2"}]`,
+			map[string]interface{}{"a": "1", "b": "2"},
+		},
+		{
+			"replace existing field",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "replace", "path": "/a", "value": "2"}]`,
+			map[string]interface{}{"a": "2"},
+		},
+		{
+			"remove field",
+			map[string]interface{}{"a": "1", "b": "2"},
+			`[{"op": "remove", "path": "/b"}]`,
+			map[string]interface{}{"a": "1"},
+		},
+		{
+			"move field",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "move", "from": "/a", "path": "/b"}]`,
+			map[string]interface{}{"b": "1"},
+		},
+		{
+			"copy field",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "copy", "from": "/a", "path": "/b"}]`,
+			map[string]interface{}{"a": "1", "b": "1"},
+		},
+		{
+			"add into nested path",
+			map[string]interface{}{"metadata": map[string]interface{}{"name": "foo"}},
+			`[{"op": "add", "path": "/metadata/namespace", "value": "team-a"}]`,
+			map[string]interface{}{"metadata": map[string]interface{}{"name": "foo", "namespace": "team-a"}},
+		},
+		{
+			"replace escapes ~1 and ~0 in pointer segments",
+			map[string]interface{}{"a/b": map[string]interface{}{"c~d": "1"}},
+			`[{"op": "replace", "path": "/a~1b/c~0d", "value": "2"}]`,
+			map[string]interface{}{"a/b": map[string]interface{}{"c~d": "2"}},
+		},
+		{
+			"passing test op is a no-op",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "test", "path": "/a", "value": "1"}, {"op": "replace", "path": "/a", "value": "2"}]`,
+			map[string]interface{}{"a": "2"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := applyJSONPatch(tc.doc, []byte(tc.ops))
+			if err != nil {
+				t.Fatalf("applyJSONPatch: %v", err)
+			}
+			gotMap, ok := got.(map[string]interface{})
+			if !ok {
+				t.Fatalf("expected a map[string]interface{}, got %T", got)
+			}
+			if !mapsEqual(gotMap, tc.want) {
+				t.Errorf("applyJSONPatch() = %v, want %v", gotMap, tc.want)
+			}
+		})
+	}
+}
+
+// TestApplyJSONPatch_ArrayOps covers array-specific index handling: "-"
+// append, insert-by-index shifting later elements, and in-place replace.
+func TestApplyJSONPatch_ArrayOps(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  map[string]interface{}
+		ops  string
+		want []interface{}
+	}{
+		{
+			"append with -",
+			map[string]interface{}{"items": []interface{}{"a", "b"}},
+			`[{"op": "add", "path": "/items/-", "value": "c"}]`,
+			[]interface{}{"a", "b", "c"},
+		},
+		{
+			"insert shifts later elements",
+			map[string]interface{}{"items": []interface{}{"a", "c"}},
+			`[{"op": "add", "path": "/items/1", "value": "b"}]`,
+			[]interface{}{"a", "b", "c"},
+		},
+		{
+			"replace by index",
+			map[string]interface{}{"items": []interface{}{"a", "b"}},
+			`[{"op": "replace", "path": "/items/1", "value": "z"}]`,
+			[]interface{}{"a", "z"},
+		},
+		{
+			"remove by index",
+			map[string]interface{}{"items": []interface{}{"a", "b", "c"}},
+			`[{"op": "remove", "path": "/items/1"}]`,
+			[]interface{}{"a", "c"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := applyJSONPatch(tc.doc, []byte(tc.ops))
+			if err != nil {
+				t.Fatalf("applyJSONPatch: %v", err)
+			}
+			gotMap := got.(map[string]interface{})
+			if !sliceEqual(gotMap["items"].([]interface{}), tc.want) {
+				t.Errorf("items = %v, want %v", gotMap["items"], tc.want)
+			}
+		})
+	}
+}
+
+// TestApplyJSONPatch_Errors covers the documented failure paths: a failing
+// test op, an out-of-range replace index, and an unsupported op.
+func TestApplyJSONPatch_Errors(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  map[string]interface{}
+		ops  string
+	}{
+		{
+			"failing test op",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "test", "path": "/a", "value": "2"}]`,
+		},
+		{
+			"replace out of range index",
+			map[string]interface{}{"items": []interface{}{"a"}},
+			`[{"op": "replace", "path": "/items/5", "value": "x"}]`,
+		},
+		{
+			"unsupported op",
+			map[string]interface{}{"a": "1"},
+			`[{"op": "bogus", "path": "/a", "value": "2"}]`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := applyJSONPatch(tc.doc, []byte(tc.ops)); err == nil {
+				t.Errorf("expected an error, got nil")
+			}
+		})
+	}
+}
+
+// TestPatchNodeInPlace_PreservesUntouchedFields ensures that applying a
+// patch to one field leaves an unrelated field -- including its comment --
+// alone, since patchNodeInPlace reconciles through applyMapToRNode rather
+// than replacing the node wholesale.
+func TestPatchNodeInPlace_PreservesUntouchedFields(t *testing.T) {
+	node := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  # a trailing comment on an untouched field
+  untouched: original
+  keep: original
+`)
+	patch := Patch{
+		Type:  JSONPatchType,
+		Patch: `[{"op": "replace", "path": "/data/keep", "value": "replaced"}, {"op": "add", "path": "/data/added", "value": "new"}]`,
+	}
+	if err := patchNodeInPlace(node, patch); err != nil {
+		t.Fatalf("patchNodeInPlace: %v", err)
+	}
+
+	s := node.MustString()
+	if !strings.Contains(s, "# a trailing comment on an untouched field") {
+		t.Errorf("expected comment to survive patching, got:\n%s", s)
+	}
+
+	m, err := toMap(node)
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+	data, _ := m["data"].(map[string]interface{})
+	if data["keep"] != "replaced" {
+		t.Errorf("expected data.keep to be replaced, got %v", data["keep"])
+	}
+	if data["added"] != "new" {
+		t.Errorf("expected data.added to be added, got %v", data["added"])
+	}
+}
+
+// TestPatchNodeInPlace_MergePatch covers the RFC 7396 JSON Merge Patch path,
+// including null-deletes-a-key.
+func TestPatchNodeInPlace_MergePatch(t *testing.T) {
+	node := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  a: "1"
+  b: "2"
+`)
+	patch := Patch{
+		Type:  JSONMergePatchType,
+		Patch: `{"data": {"a": "11", "b": null, "c": "3"}}`,
+	}
+	if err := patchNodeInPlace(node, patch); err != nil {
+		t.Fatalf("patchNodeInPlace: %v", err)
+	}
+
+	m, err := toMap(node)
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+	data, _ := m["data"].(map[string]interface{})
+	if data["a"] != "11" {
+		t.Errorf("expected data.a to be updated, got %v", data["a"])
+	}
+	if _, ok := data["b"]; ok {
+		t.Errorf("expected data.b to be removed by a null merge patch value, still present: %v", data["b"])
+	}
+	if data["c"] != "3" {
+		t.Errorf("expected data.c to be added, got %v", data["c"])
+	}
+}
+
+func mapsEqual(a, b map[string]interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, av := range a {
+		bv, ok := b[k]
+		if !ok {
+			return false
+		}
+		if am, ok := av.(map[string]interface{}); ok {
+			bm, ok := bv.(map[string]interface{})
+			if !ok || !mapsEqual(am, bm) {
+				return false
+			}
+			continue
+		}
+		if as, ok := av.([]interface{}); ok {
+			bs, ok := bv.([]interface{})
+			if !ok || !sliceEqual(as, bs) {
+				return false
+			}
+			continue
+		}
+		if av != bv {
+			return false
+		}
+	}
+	return true
+}
+
+func sliceEqual(a, b []interface{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
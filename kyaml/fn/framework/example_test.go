@@ -6,6 +6,7 @@ package framework_test
 import (
 	"bytes"
 	"fmt"
+	"testing/fstest"
 
 	"sigs.k8s.io/kustomize/kyaml/fn/framework"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
@@ -280,6 +281,184 @@ functionConfig:
 	//       a: b
 }
 
+// ExampleTemplateCommand generates a Service from an fs.FS of templates, replacing
+// the boilerplate item-scanning loop in ExampleCommand_generateReplace with
+// a single rendered template.
+func ExampleTemplateCommand() {
+	// function API definition which will be parsed from the ResourceList.functionConfig
+	// read from stdin
+	type Spec struct {
+		Name string `yaml:"name,omitempty"`
+	}
+	type ExampleServiceGenerator struct {
+		Spec Spec `yaml:"spec,omitempty"`
+	}
+	functionConfig := &ExampleServiceGenerator{}
+
+	templates := fstest.MapFS{
+		"service.yaml": &fstest.MapFile{Data: []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .Spec.Name }}
+`)},
+	}
+
+	cmd := framework.TemplateCommand("example-service-generator", functionConfig, templates, framework.TemplateOptions{})
+
+	// for testing purposes only -- normally read from stdin when Executing
+	cmd.SetIn(bytes.NewBufferString(`
+apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: foo
+functionConfig:
+  apiVersion: example.com/v1alpha1
+  kind: ExampleServiceGenerator
+  spec:
+    name: bar
+`))
+
+	// run the command
+	if err := cmd.Execute(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// apiVersion: config.kubernetes.io/v1alpha1
+	// kind: ResourceList
+	// items:
+	// - apiVersion: apps/v1
+	//   kind: Deployment
+	//   metadata:
+	//     name: foo
+	// - apiVersion: v1
+	//   kind: Service
+	//   metadata:
+	//     name: bar
+	//     annotations:
+	//       config.kubernetes.io/generated-by: 'example-service-generator/service.yaml'
+	// functionConfig:
+	//   apiVersion: example.com/v1alpha1
+	//   kind: ExampleServiceGenerator
+	//   spec:
+	//     name: bar
+}
+
+// ExampleNewReadinessValidator reports that a Deployment is not ready
+// because status.readyReplicas has not caught up with spec.replicas.
+func ExampleNewReadinessValidator() {
+	cmd := framework.Command(nil, framework.NewReadinessValidator())
+
+	// for testing purposes only -- normally read from stdin when Executing
+	cmd.SetIn(bytes.NewBufferString(`
+apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: foo
+    generation: 1
+  spec:
+    replicas: 3
+  status:
+    observedGeneration: 1
+    readyReplicas: 1
+`))
+
+	if err := cmd.Execute(); err != nil {
+		// normally exit 1 here
+	}
+
+	// Output:
+	// apiVersion: config.kubernetes.io/v1alpha1
+	// kind: ResourceList
+	// items:
+	// - apiVersion: apps/v1
+	//   kind: Deployment
+	//   metadata:
+	//     name: foo
+	//     generation: 1
+	//   spec:
+	//     replicas: 3
+	//   status:
+	//     observedGeneration: 1
+	//     readyReplicas: 1
+	// results:
+	//   name: readiness-validator
+	//   items:
+	//   - message: 1/3 replicas ready
+	//     severity: warning
+	//     resourceRef:
+	//       apiVersion: apps/v1
+	//       kind: Deployment
+	//       metadata:
+	//         name: foo
+}
+
+// ExampleCommand_selector generates a ConfigMap, but only considers
+// previously-generated ConfigMaps (identified via a label selector) when
+// deciding whether one already exists. Resources outside the selector, like
+// the Deployment below, are never passed to the function and are spliced
+// back into ResourceList.items unchanged.
+func ExampleCommand_selector() {
+	sel := framework.Selector{
+		Kind:        "ConfigMap",
+		MatchLabels: map[string]string{"generated-by": "example"},
+	}
+
+	cmd := framework.Command(nil, func(items []*yaml.RNode) ([]*yaml.RNode, error) {
+		if len(items) > 0 {
+			// already generated, leave it alone
+			return items, nil
+		}
+		n, err := yaml.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: generated
+  labels:
+    generated-by: example
+`)
+		if err != nil {
+			return nil, err
+		}
+		return []*yaml.RNode{n}, nil
+	}, framework.WithSelector(sel))
+
+	// for testing purposes only -- normally read from stdin when Executing
+	cmd.SetIn(bytes.NewBufferString(`
+apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: foo
+`))
+
+	if err := cmd.Execute(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// apiVersion: config.kubernetes.io/v1alpha1
+	// kind: ResourceList
+	// items:
+	// - apiVersion: v1
+	//   kind: ConfigMap
+	//   metadata:
+	//     name: generated
+	//     labels:
+	//       generated-by: example
+	// - apiVersion: apps/v1
+	//   kind: Deployment
+	//   metadata:
+	//     name: foo
+}
+
 // ExampleCommand_validate validates that all Deployment resources have the replicas field set.
 // If any Deployments do not contain spec.replicas, then the function will return Results
 // which will be set on ResourceList.results
@@ -366,3 +545,77 @@ items:
 	//       path: spec.field
 	//       suggestedValue: "1"
 }
+
+// ExampleAutofixCommand runs the same validation as ExampleCommand_validate,
+// but attaches a Fix-severity Patch to the finding so AutofixCommand applies
+// it automatically; the Deployment comes back with spec.replicas set and no
+// remaining results.
+func ExampleAutofixCommand() {
+	validate := func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		var validationResults []framework.Item
+		for i := range nodes {
+			meta, err := nodes[i].GetMeta()
+			if err != nil {
+				return nil, err
+			}
+			if meta.Kind != "Deployment" {
+				continue
+			}
+
+			r, err := nodes[i].Pipe(yaml.Lookup("spec", "replicas"))
+			if err != nil {
+				return nil, err
+			}
+			if r != nil {
+				continue
+			}
+
+			validationResults = append(validationResults, framework.Item{
+				Severity:    framework.Fix,
+				Message:     "missing replicas",
+				ResourceRef: meta,
+				Field: framework.Field{
+					Path:           "spec.replicas",
+					SuggestedValue: "1",
+				},
+				Patch: &framework.Patch{
+					Type:  framework.JSONMergePatchType,
+					Patch: `{"spec":{"replicas":1}}`,
+				},
+			})
+		}
+		return nodes, framework.Result{
+			Name:  "replicas-validator",
+			Items: validationResults,
+		}
+	}
+
+	cmd := framework.AutofixCommand(nil, validate)
+
+	// for testing purposes only -- normally read from stdin when Executing
+	cmd.SetIn(bytes.NewBufferString(`
+apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: apps/v1
+  kind: Deployment
+  metadata:
+    name: foo
+`))
+
+	// run the command
+	if err := cmd.Execute(); err != nil {
+		panic(err)
+	}
+
+	// Output:
+	// apiVersion: config.kubernetes.io/v1alpha1
+	// kind: ResourceList
+	// items:
+	// - apiVersion: apps/v1
+	//   kind: Deployment
+	//   metadata:
+	//     name: foo
+	//   spec:
+	//     replicas: 1
+}
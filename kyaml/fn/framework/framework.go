@@ -0,0 +1,258 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package framework contains libraries for building functions that operate
+// on KRM ResourceLists in the manner described by
+// https://github.com/kubernetes-sigs/kustomize/blob/master/cmd/config/docs/api-conventions/functions-spec.md
+package framework
+
+import (
+	"io"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ResourceListProcessor is the signature of the function invoked by Command
+// once ResourceList.items and ResourceList.functionConfig have been parsed.
+// It returns the (possibly modified) items that should be written back to
+// ResourceList.items, or an error. A returned Result populates
+// ResourceList.results and the output is still written; Command then
+// returns the Result as its error only if it HasFailure.
+type ResourceListProcessor func(items []*yaml.RNode) ([]*yaml.RNode, error)
+
+// ResourceList is the input/output wire format for functions: a list of
+// resources plus the function's configuration and any results it produced.
+type ResourceList struct {
+	// APIVersion and Kind identify the ResourceList document itself.
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+
+	// Items holds the resources the function operates on.
+	Items []*yaml.RNode `yaml:"items"`
+
+	// FunctionConfig holds the raw functionConfig node so that it can be
+	// round-tripped to the output unmodified.
+	FunctionConfig *yaml.RNode `yaml:"functionConfig,omitempty"`
+
+	// Results holds structured information produced by the function, e.g.
+	// validation errors and warnings.
+	Results *Result `yaml:"results,omitempty"`
+}
+
+// Option configures the Command returned by Command.
+type Option func(*options)
+
+type options struct {
+	selector Matcher
+}
+
+// WithSelector restricts the items passed to the function to those matched
+// by sel; items that don't match are spliced back into ResourceList.items
+// untouched once the function returns. sel is typically a Selector or a Set
+// built with AnyOf.
+func WithSelector(sel Matcher) Option {
+	return func(o *options) {
+		o.selector = sel
+	}
+}
+
+// Command returns a cobra.Command that reads a ResourceList from stdin,
+// parses functionConfig, invokes function with ResourceList.items, and
+// writes the resulting ResourceList to stdout.
+//
+// If functionConfig is non-nil, the parsed ResourceList.functionConfig is
+// unmarshalled into it. If functionConfig is nil, ResourceList.functionConfig
+// is instead treated as a ConfigMap and its `data` entries are applied as
+// flag values on the returned Command, so that simple functions can be
+// configured without declaring a functionConfig type at all.
+func Command(functionConfig interface{}, function ResourceListProcessor, opts ...Option) *cobra.Command {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cmd := &cobra.Command{
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rl, err := readResourceList(cmd.InOrStdin())
+			if err != nil {
+				return errors.Wrap(err)
+			}
+			if err := rl.parseFunctionConfig(cmd, functionConfig); err != nil {
+				return errors.Wrap(err)
+			}
+
+			sel := o.selector
+			fromConfig, err := selectorFromFunctionConfig(rl.FunctionConfig)
+			if err != nil {
+				return errors.Wrap(err)
+			}
+			if fromConfig != nil {
+				sel = fromConfig
+			}
+
+			matched, untouched := rl.Items, []*yaml.RNode(nil)
+			if sel != nil {
+				matched, untouched, err = sel.Select(rl.Items)
+				if err != nil {
+					return errors.Wrap(err)
+				}
+			}
+
+			newItems, fnErr := function(matched)
+			var result Result
+			if r, ok := fnErr.(Result); ok {
+				result, fnErr = r, nil
+			}
+			if fnErr != nil {
+				return errors.Wrap(fnErr)
+			}
+
+			rl.Items = append(newItems, untouched...)
+			if len(result.Items) > 0 {
+				rl.Results = &result
+			}
+
+			if err := writeResourceList(cmd.OutOrStdout(), rl); err != nil {
+				return errors.Wrap(err)
+			}
+			if rl.Results != nil && result.HasFailure() {
+				return result
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+// parseFunctionConfig unmarshals rl.FunctionConfig into functionConfig when
+// it is non-nil, or, when functionConfig is nil, applies its `data` entries
+// as flags on cmd so that ConfigMap-shaped functionConfig can drive simple
+// functions without a dedicated type.
+func (rl *ResourceList) parseFunctionConfig(cmd *cobra.Command, functionConfig interface{}) error {
+	if rl.FunctionConfig == nil {
+		return nil
+	}
+	if functionConfig != nil {
+		return yaml.Unmarshal([]byte(rl.FunctionConfig.MustString()), functionConfig)
+	}
+
+	data, err := rl.FunctionConfig.Pipe(yaml.Lookup("data"))
+	if err != nil || data == nil {
+		return err
+	}
+	fields, err := data.Fields()
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		value, err := data.Pipe(yaml.Lookup(field))
+		if err != nil {
+			return err
+		}
+		if f := cmd.Flags().Lookup(field); f != nil {
+			if err := f.Value.Set(value.YNode().Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readResourceList parses the ResourceList wire format by hand rather than
+// generically unmarshalling into *ResourceList: yaml.RNode has no
+// (Un)MarshalYAML methods, so items and functionConfig are navigated with
+// Lookup/Elements instead, the same way the rest of this package reads
+// resource content.
+func readResourceList(r io.Reader) (*ResourceList, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := yaml.Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+
+	rl := &ResourceList{
+		APIVersion: lookupString(root, "apiVersion"),
+		Kind:       lookupString(root, "kind"),
+	}
+
+	items, err := root.Pipe(yaml.Lookup("items"))
+	if err != nil {
+		return nil, err
+	}
+	if items != nil {
+		if rl.Items, err = items.Elements(); err != nil {
+			return nil, err
+		}
+	}
+
+	if rl.FunctionConfig, err = root.Pipe(yaml.Lookup("functionConfig")); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+// writeResourceList is the inverse of readResourceList: it assembles the
+// output document field by field instead of generically marshalling
+// *ResourceList, since yaml.RNode has no (Un)MarshalYAML methods and would
+// otherwise serialize as its lone exported Match field.
+func writeResourceList(w io.Writer, rl *ResourceList) error {
+	root, err := yaml.Parse("{}\n")
+	if err != nil {
+		return err
+	}
+
+	if rl.APIVersion != "" {
+		if err := root.PipeE(yaml.LookupCreate(yaml.ScalarNode, "apiVersion"), yaml.FieldSetter{StringValue: rl.APIVersion}); err != nil {
+			return err
+		}
+	}
+	if rl.Kind != "" {
+		if err := root.PipeE(yaml.LookupCreate(yaml.ScalarNode, "kind"), yaml.FieldSetter{StringValue: rl.Kind}); err != nil {
+			return err
+		}
+	}
+
+	items, err := root.Pipe(yaml.LookupCreate(yaml.SequenceNode, "items"))
+	if err != nil {
+		return err
+	}
+	seq := items.YNode()
+	for _, item := range rl.Items {
+		seq.Content = append(seq.Content, item.YNode())
+	}
+
+	if rl.FunctionConfig != nil {
+		if err := root.PipeE(yaml.SetField("functionConfig", rl.FunctionConfig)); err != nil {
+			return err
+		}
+	}
+
+	if rl.Results != nil {
+		b, err := yaml.Marshal(rl.Results)
+		if err != nil {
+			return err
+		}
+		resultsNode, err := yaml.Parse(string(b))
+		if err != nil {
+			return err
+		}
+		if err := root.PipeE(yaml.SetField("results", resultsNode)); err != nil {
+			return err
+		}
+	}
+
+	out, err := root.String()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write([]byte(out))
+	return err
+}
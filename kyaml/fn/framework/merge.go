@@ -0,0 +1,406 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// lastAppliedAnnotation stores the gzipped, base64-encoded YAML of the
+// desired state a MergeGenerator last applied. It plays the role of the
+// "original" side of a three-way merge, the same way
+// kubectl.kubernetes.io/last-applied-configuration does for `kubectl apply`.
+const lastAppliedAnnotation = "config.kubernetes.io/last-applied-by-fn"
+
+// listElementKeyFuncs maps the name of a well-known Kubernetes list field to
+// a function identifying its elements across a three-way merge, mirroring
+// the patchMergeKey tags Kubernetes puts on PodSpec and friends.
+var listElementKeyFuncs = map[string]func(map[string]interface{}) string{
+	"containers":     fieldKeyFunc("name"),
+	"initContainers": fieldKeyFunc("name"),
+	"env":            fieldKeyFunc("name"),
+	"volumes":        fieldKeyFunc("name"),
+	"volumeMounts":   fieldKeyFunc("name"),
+	"ports":          portKeyFunc,
+}
+
+func fieldKeyFunc(field string) func(map[string]interface{}) string {
+	return func(m map[string]interface{}) string {
+		return fmt.Sprintf("%v", m[field])
+	}
+}
+
+// portKeyFunc identifies a port list element by containerPort+protocol, the
+// patchMergeKey Kubernetes uses for a Pod/Container's spec.ports. Service
+// ports have no containerPort field at all, so it falls back to port (also
+// combined with protocol, since e.g. TCP/UDP port 53 are distinct ports).
+func portKeyFunc(m map[string]interface{}) string {
+	port, ok := m["containerPort"]
+	if !ok {
+		port = m["port"]
+	}
+	protocol := m["protocol"]
+	if protocol == nil || protocol == "" {
+		protocol = "TCP"
+	}
+	return fmt.Sprintf("%v/%v", port, protocol)
+}
+
+// MergeSummary describes what a MergeGenerator call changed.
+type MergeSummary struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// MergeGenerator reconciles desired, a node a generator function just
+// produced, against the matching item (by apiVersion/kind/namespace/name) in
+// items using a three-way merge in the style of `kubectl apply`: the
+// "original" side is the last-applied snapshot stored on the in-list item's
+// lastAppliedAnnotation, "modified" is desired, and "current" is the in-list
+// item itself. Fields desired no longer sets but previously did are removed;
+// fields the user added that neither side owns are preserved. It returns the
+// updated items slice, a Result item summarizing the merge, and an error.
+func MergeGenerator(items []*yaml.RNode, desired *yaml.RNode) ([]*yaml.RNode, Item, error) {
+	meta, err := desired.GetMeta()
+	if err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+
+	idx := -1
+	for i, item := range items {
+		m, err := item.GetMeta()
+		if err != nil {
+			return nil, Item{}, errors.Wrap(err)
+		}
+		if m.APIVersion == meta.APIVersion && m.Kind == meta.Kind &&
+			m.Namespace == meta.Namespace && m.Name == meta.Name {
+			idx = i
+			break
+		}
+	}
+
+	modified, err := toMap(desired)
+	if err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+
+	if idx < 0 {
+		// nothing to reconcile against -- this is the first time we've
+		// generated this resource.
+		merged, err := fromMapWithLastApplied(modified, modified)
+		if err != nil {
+			return nil, Item{}, errors.Wrap(err)
+		}
+		items = append(items, merged)
+		return items, Item{
+			Severity:    Info,
+			Message:     "generated new resource",
+			ResourceRef: meta,
+		}, nil
+	}
+
+	current, err := toMap(items[idx])
+	if err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+	original, err := lastApplied(items[idx])
+	if err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+	if original == nil {
+		original = map[string]interface{}{}
+	}
+
+	var summary MergeSummary
+	merged := mergeMaps(original, modified, current, "", &summary)
+	if err := applyMapToRNode(items[idx], merged); err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+	snapshot, err := encodeLastApplied(modified)
+	if err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+	if err := items[idx].PipeE(yaml.SetAnnotation(lastAppliedAnnotation, snapshot)); err != nil {
+		return nil, Item{}, errors.Wrap(err)
+	}
+
+	return items, Item{
+		Severity:    Info,
+		Message:     fmt.Sprintf("merged: %d added, %d changed, %d removed", len(summary.Added), len(summary.Changed), len(summary.Removed)),
+		ResourceRef: meta,
+	}, nil
+}
+
+// mergeMaps three-way merges original/modified/current, preferring fields
+// the fn added or changed in modified, dropping fields it owned in original
+// but no longer sets, and preserving everything else found in current.
+func mergeMaps(original, modified, current map[string]interface{}, path string, sum *MergeSummary) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key := range unionKeys(original, modified, current) {
+		fieldPath := joinPath(path, key)
+		m, inModified := modified[key]
+		o, inOriginal := original[key]
+		c, inCurrent := current[key]
+
+		if !inModified {
+			if inOriginal {
+				sum.Removed = append(sum.Removed, fieldPath)
+				continue
+			}
+			if inCurrent {
+				result[key] = c
+			}
+			continue
+		}
+
+		result[key] = mergeValue(o, m, c, fieldPath, sum)
+		if !inOriginal {
+			sum.Added = append(sum.Added, fieldPath)
+		} else if !reflect.DeepEqual(o, m) {
+			sum.Changed = append(sum.Changed, fieldPath)
+		}
+	}
+	return result
+}
+
+func mergeValue(original, modified, current interface{}, path string, sum *MergeSummary) interface{} {
+	if m, ok := modified.(map[string]interface{}); ok {
+		o, _ := original.(map[string]interface{})
+		c, _ := current.(map[string]interface{})
+		return mergeMaps(o, m, c, path, sum)
+	}
+	if m, ok := modified.([]interface{}); ok {
+		if keyFn, ok := listElementKeyFuncs[lastSegment(path)]; ok {
+			o, _ := original.([]interface{})
+			c, _ := current.([]interface{})
+			return mergeList(o, m, c, keyFn, path, sum)
+		}
+	}
+	return modified
+}
+
+// mergeList three-way merges a list field whose elements are identified by
+// keyFn (e.g. containers by name), recursing into each matched element so
+// per-element user edits survive. Elements current has that modified
+// doesn't are kept unless the fn previously owned them (per originalByKey),
+// in which case they're dropped, mirroring the map-level removal in
+// mergeMaps.
+func mergeList(original, modified, current []interface{}, keyFn func(map[string]interface{}) string, path string, sum *MergeSummary) []interface{} {
+	originalByKey := indexByKey(original, keyFn)
+	currentByKey := indexByKey(current, keyFn)
+	seen := map[string]bool{}
+
+	var out []interface{}
+	for _, m := range modified {
+		mm, ok := m.(map[string]interface{})
+		if !ok {
+			out = append(out, m)
+			continue
+		}
+		id := keyFn(mm)
+		seen[id] = true
+		elementPath := fmt.Sprintf("%s[%s]", path, id)
+		out = append(out, mergeMaps(originalByKey[id], mm, currentOrEmpty(currentByKey, id, mm), elementPath, sum))
+	}
+	for id, c := range currentByKey {
+		if seen[id] {
+			continue
+		}
+		if _, ownedByFn := originalByKey[id]; ownedByFn {
+			// the fn previously generated this element and no longer does
+			sum.Removed = append(sum.Removed, fmt.Sprintf("%s[%s]", path, id))
+			continue
+		}
+		// never owned by the fn, e.g. a user-added sidecar container
+		out = append(out, c)
+	}
+	return out
+}
+
+func currentOrEmpty(byKey map[string]map[string]interface{}, id string, fallback map[string]interface{}) map[string]interface{} {
+	if c, ok := byKey[id]; ok {
+		return c
+	}
+	return fallback
+}
+
+func indexByKey(list []interface{}, keyFn func(map[string]interface{}) string) map[string]map[string]interface{} {
+	out := map[string]map[string]interface{}{}
+	for _, el := range list {
+		if m, ok := el.(map[string]interface{}); ok {
+			out[keyFn(m)] = m
+		}
+	}
+	return out
+}
+
+func unionKeys(maps ...map[string]interface{}) map[string]struct{} {
+	out := map[string]struct{}{}
+	for _, m := range maps {
+		for k := range m {
+			out[k] = struct{}{}
+		}
+	}
+	return out
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func lastSegment(path string) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == '.' {
+			return path[i+1:]
+		}
+	}
+	return path
+}
+
+// applyMapToRNode reconciles node's mapping fields against desired in place:
+// fields node has that desired doesn't are cleared, and every field in
+// desired is set via setRNodeField. Unlike bouncing the whole document
+// through yaml.Marshal/yaml.Parse, this edits node and its nested mapping
+// nodes in place, so field order and comments on their keys survive. Every
+// field in desired is set again whether or not its value changed.
+func applyMapToRNode(node *yaml.RNode, desired map[string]interface{}) error {
+	fields, err := node.Fields()
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if _, ok := desired[field]; !ok {
+			if err := node.PipeE(yaml.Clear(field)); err != nil {
+				return err
+			}
+		}
+	}
+	for key, value := range desired {
+		if err := setRNodeField(node, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setRNodeField sets node's key field to value in place. A nested object
+// recurses into the existing child node (or a newly created one) so its
+// untouched fields are preserved; a list or scalar value is rendered to a
+// small standalone RNode and attached wholesale, since neither has a
+// meaningful "existing node" to merge into below the field itself.
+func setRNodeField(node *yaml.RNode, key string, value interface{}) error {
+	if value == nil {
+		return node.PipeE(yaml.Clear(key))
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		child, err := node.Pipe(yaml.LookupCreate(yaml.MappingNode, key))
+		if err != nil {
+			return err
+		}
+		return applyMapToRNode(child, m)
+	}
+	child, err := valueToRNode(value)
+	if err != nil {
+		return err
+	}
+	return node.PipeE(yaml.SetField(key, child))
+}
+
+// valueToRNode renders a single Go value (as decoded by yaml.Unmarshal into
+// an interface{}) back into an *yaml.RNode.
+func valueToRNode(value interface{}) (*yaml.RNode, error) {
+	b, err := yaml.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Parse(string(b))
+}
+
+func toMap(node *yaml.RNode) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(node.MustString()), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func fromMapWithLastApplied(m, applied map[string]interface{}) (*yaml.RNode, error) {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	node, err := yaml.Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := encodeLastApplied(applied)
+	if err != nil {
+		return nil, err
+	}
+	if err := node.PipeE(yaml.SetAnnotation(lastAppliedAnnotation, snapshot)); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func lastApplied(node *yaml.RNode) (map[string]interface{}, error) {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	encoded, ok := meta.Annotations[lastAppliedAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	return decodeLastApplied(encoded)
+}
+
+func encodeLastApplied(m map[string]interface{}) (string, error) {
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(b); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+func decodeLastApplied(encoded string) (map[string]interface{}, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	b, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
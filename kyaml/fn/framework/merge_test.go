@@ -0,0 +1,216 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func mustParse(t *testing.T, s string) *yaml.RNode {
+	t.Helper()
+	node, err := yaml.Parse(s)
+	if err != nil {
+		t.Fatalf("yaml.Parse: %v", err)
+	}
+	return node
+}
+
+// withData sets a value deep inside a map produced by toMap, for simulating
+// a user hand-editing a previously generated resource between two runs.
+func withData(t *testing.T, node *yaml.RNode, path []string, value interface{}) *yaml.RNode {
+	t.Helper()
+	m, err := toMap(node)
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+	cur := m
+	for _, p := range path[:len(path)-1] {
+		next, ok := cur[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			cur[p] = next
+		}
+		cur = next
+	}
+	cur[path[len(path)-1]] = value
+
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	return mustParse(t, string(b))
+}
+
+// TestMergeGenerator_AddChangeRemoveFields exercises MergeGenerator across
+// two runs: the first run generates a resource from scratch, the second run
+// changes one field, removes another, and a user-added field that neither
+// run owns survives both.
+func TestMergeGenerator_AddChangeRemoveFields(t *testing.T) {
+	desired1 := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  a: "1"
+  b: "2"
+`)
+	items, _, err := MergeGenerator(nil, desired1)
+	if err != nil {
+		t.Fatalf("MergeGenerator (create): %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(items))
+	}
+
+	// simulate a user hand-editing the generated ConfigMap to add a field
+	// the fn has never owned.
+	items[0] = withData(t, items[0], []string{"data", "c"}, "user-added")
+
+	desired2 := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: foo
+data:
+  a: "11"
+`)
+	items, item, err := MergeGenerator(items, desired2)
+	if err != nil {
+		t.Fatalf("MergeGenerator (update): %v", err)
+	}
+	if item.Severity != Info {
+		t.Errorf("expected Info severity, got %v", item.Severity)
+	}
+
+	merged, err := toMap(items[0])
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+	data, _ := merged["data"].(map[string]interface{})
+	if data["a"] != "11" {
+		t.Errorf("expected data.a to be changed to 11, got %v", data["a"])
+	}
+	if _, ok := data["b"]; ok {
+		t.Errorf("expected data.b (removed by the fn) to be dropped, still present: %v", data["b"])
+	}
+	if data["c"] != "user-added" {
+		t.Errorf("expected user-added data.c to be preserved, got %v", data["c"])
+	}
+}
+
+// TestMergeGenerator_ListMerge covers containers (merged by name) and ports
+// (merged by containerPort+protocol): an element the fn stops emitting is
+// removed, an element it still emits is updated in place, and an element a
+// user added outside the fn survives.
+func TestMergeGenerator_ListMerge(t *testing.T) {
+	desired1 := mustParse(t, `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: foo
+containers:
+- name: app
+  image: app:v1
+- name: sidecar
+  image: sidecar:v1
+ports:
+- containerPort: 53
+  protocol: TCP
+- containerPort: 53
+  protocol: UDP
+`)
+	items, _, err := MergeGenerator(nil, desired1)
+	if err != nil {
+		t.Fatalf("MergeGenerator (create): %v", err)
+	}
+
+	// simulate a user adding their own debug container outside the fn.
+	m, err := toMap(items[0])
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+	containers, _ := m["containers"].([]interface{})
+	m["containers"] = append(containers, map[string]interface{}{"name": "debug", "image": "debug:latest"})
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	items[0] = mustParse(t, string(b))
+
+	desired2 := mustParse(t, `
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: foo
+containers:
+- name: app
+  image: app:v2
+ports:
+- containerPort: 53
+  protocol: TCP
+- containerPort: 8080
+  protocol: TCP
+`)
+	items, _, err = MergeGenerator(items, desired2)
+	if err != nil {
+		t.Fatalf("MergeGenerator (update): %v", err)
+	}
+
+	merged, err := toMap(items[0])
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+
+	containerNames := map[string]string{}
+	for _, el := range merged["containers"].([]interface{}) {
+		c := el.(map[string]interface{})
+		containerNames[c["name"].(string)] = c["image"].(string)
+	}
+	if len(containerNames) != 2 {
+		t.Fatalf("expected 2 containers (app, debug), got %v", containerNames)
+	}
+	if containerNames["app"] != "app:v2" {
+		t.Errorf("expected app image to be updated to app:v2, got %v", containerNames["app"])
+	}
+	if containerNames["debug"] != "debug:latest" {
+		t.Errorf("expected user-added debug container to survive, got %v", containerNames)
+	}
+	if _, ok := containerNames["sidecar"]; ok {
+		t.Errorf("expected sidecar container (dropped by the fn) to be removed, containers=%v", containerNames)
+	}
+
+	portKeys := map[string]bool{}
+	for _, el := range merged["ports"].([]interface{}) {
+		p := el.(map[string]interface{})
+		portKeys[portKeyFunc(p)] = true
+	}
+	if !portKeys["53/TCP"] || !portKeys["8080/TCP"] {
+		t.Errorf("expected 53/TCP and 8080/TCP ports, got %v", portKeys)
+	}
+	if portKeys["53/UDP"] {
+		t.Errorf("expected 53/UDP (dropped by the fn) to be removed, got %v", portKeys)
+	}
+}
+
+func TestPortKeyFunc(t *testing.T) {
+	cases := []struct {
+		name string
+		m    map[string]interface{}
+		want string
+	}{
+		{"container port with protocol", map[string]interface{}{"containerPort": 53, "protocol": "UDP"}, "53/UDP"},
+		{"container port defaults to TCP", map[string]interface{}{"containerPort": 8080}, "8080/TCP"},
+		{"service port has no containerPort", map[string]interface{}{"port": 80, "protocol": "TCP"}, "80/TCP"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := portKeyFunc(tc.m); got != tc.want {
+				t.Errorf("portKeyFunc(%v) = %q, want %q", tc.m, got, tc.want)
+			}
+		})
+	}
+}
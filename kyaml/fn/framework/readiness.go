@@ -0,0 +1,247 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ReadinessStatus is the outcome of a ReadinessCheckFunc.
+type ReadinessStatus string
+
+const (
+	Ready    ReadinessStatus = "Ready"
+	NotReady ReadinessStatus = "NotReady"
+	Unknown  ReadinessStatus = "Unknown"
+)
+
+// GVK identifies the apiVersion/kind a readiness check applies to.
+type GVK struct {
+	APIVersion string
+	Kind       string
+}
+
+// ReadinessCheckFunc inspects a resource and reports whether it is ready.
+type ReadinessCheckFunc func(node *yaml.RNode) (ReadinessStatus, string)
+
+var readinessChecks = map[GVK]ReadinessCheckFunc{
+	{APIVersion: "apps/v1", Kind: "Deployment"}:                               checkDeploymentReady,
+	{APIVersion: "apps/v1", Kind: "StatefulSet"}:                              checkStatefulSetReady,
+	{APIVersion: "apps/v1", Kind: "DaemonSet"}:                                checkDaemonSetReady,
+	{APIVersion: "apps/v1", Kind: "ReplicaSet"}:                               checkReplicaSetReady,
+	{APIVersion: "v1", Kind: "Pod"}:                                           checkPodReady,
+	{APIVersion: "v1", Kind: "PersistentVolumeClaim"}:                         checkPVCReady,
+	{APIVersion: "v1", Kind: "Service"}:                                       checkServiceReady,
+	{APIVersion: "batch/v1", Kind: "Job"}:                                     checkJobReady,
+	{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"}: checkCRDReady,
+}
+
+// RegisterReadinessCheck registers fn as the readiness check for gvk,
+// overriding any built-in check for the same apiVersion/kind. This lets
+// callers teach ReadinessCheck about their own CRDs.
+func RegisterReadinessCheck(gvk GVK, fn ReadinessCheckFunc) {
+	readinessChecks[gvk] = fn
+}
+
+// ReadinessCheck evaluates node against the registered readiness check for
+// its apiVersion/kind and returns an Item describing the result. Kinds with
+// no registered check are treated as always ready.
+func ReadinessCheck(node *yaml.RNode) (Item, error) {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return Item{}, errors.Wrap(err)
+	}
+
+	fn, ok := readinessChecks[GVK{APIVersion: meta.APIVersion, Kind: meta.Kind}]
+	if !ok {
+		return Item{Severity: Info, Message: "no readiness check registered for this kind", ResourceRef: meta}, nil
+	}
+
+	status, msg := fn(node)
+	severity := Info
+	if status != Ready {
+		severity = Warning
+	}
+	if status == Unknown {
+		severity = Error
+	}
+	return Item{Severity: severity, Message: msg, ResourceRef: meta}, nil
+}
+
+// NewReadinessValidator returns a ResourceListProcessor that runs
+// ReadinessCheck over every item, suitable for plugging straight into
+// Command as a KRM validator function.
+func NewReadinessValidator() ResourceListProcessor {
+	return func(items []*yaml.RNode) ([]*yaml.RNode, error) {
+		result := Result{Name: "readiness-validator"}
+		for _, item := range items {
+			it, err := ReadinessCheck(item)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			result.Items = append(result.Items, it)
+		}
+		return items, result
+	}
+}
+
+func checkDeploymentReady(node *yaml.RNode) (ReadinessStatus, string) {
+	if g, o := lookupInt(node, "metadata", "generation"), lookupInt(node, "status", "observedGeneration"); o < g {
+		return NotReady, "waiting for status to reflect the latest spec"
+	}
+	replicas, ready := defaultReplicas(node), lookupInt(node, "status", "readyReplicas")
+	if ready < replicas {
+		return NotReady, fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	}
+	return Ready, "all replicas ready"
+}
+
+func checkStatefulSetReady(node *yaml.RNode) (ReadinessStatus, string) {
+	replicas, ready := defaultReplicas(node), lookupInt(node, "status", "readyReplicas")
+	if ready < replicas {
+		return NotReady, fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	}
+	if current, update := lookupString(node, "status", "currentRevision"), lookupString(node, "status", "updateRevision"); update != "" && current != update {
+		return NotReady, "rolling update in progress"
+	}
+	return Ready, "all replicas ready and up to date"
+}
+
+func checkDaemonSetReady(node *yaml.RNode) (ReadinessStatus, string) {
+	desired, ready := lookupInt(node, "status", "desiredNumberScheduled"), lookupInt(node, "status", "numberReady")
+	if ready < desired {
+		return NotReady, fmt.Sprintf("%d/%d scheduled pods ready", ready, desired)
+	}
+	return Ready, "all scheduled pods ready"
+}
+
+func checkReplicaSetReady(node *yaml.RNode) (ReadinessStatus, string) {
+	replicas, ready := defaultReplicas(node), lookupInt(node, "status", "readyReplicas")
+	if ready < replicas {
+		return NotReady, fmt.Sprintf("%d/%d replicas ready", ready, replicas)
+	}
+	return Ready, "all replicas ready"
+}
+
+func checkPodReady(node *yaml.RNode) (ReadinessStatus, string) {
+	switch phase := lookupString(node, "status", "phase"); phase {
+	case "Succeeded":
+		return Ready, "pod succeeded"
+	case "Running":
+		// fall through to condition check below
+	default:
+		return NotReady, fmt.Sprintf("pod is %s", phase)
+	}
+
+	conditions, err := node.Pipe(yaml.Lookup("status", "conditions"))
+	if err != nil || conditions == nil {
+		return Unknown, "no status conditions reported"
+	}
+	elements, err := conditions.Elements()
+	if err != nil {
+		return Unknown, err.Error()
+	}
+	for _, c := range elements {
+		if lookupString(c, "type") == "Ready" {
+			if lookupString(c, "status") == "True" {
+				return Ready, "pod is running and ready"
+			}
+			return NotReady, "pod condition Ready is not True"
+		}
+	}
+	return Unknown, "pod has no Ready condition"
+}
+
+func checkPVCReady(node *yaml.RNode) (ReadinessStatus, string) {
+	if phase := lookupString(node, "status", "phase"); phase != "Bound" {
+		return NotReady, fmt.Sprintf("PersistentVolumeClaim is %s", phase)
+	}
+	return Ready, "PersistentVolumeClaim is bound"
+}
+
+func checkServiceReady(node *yaml.RNode) (ReadinessStatus, string) {
+	if lookupString(node, "spec", "type") != "LoadBalancer" {
+		return Ready, "service does not require a load balancer"
+	}
+	ingress, err := node.Pipe(yaml.Lookup("status", "loadBalancer", "ingress"))
+	if err != nil {
+		return Unknown, err.Error()
+	}
+	if ingress == nil {
+		return NotReady, "waiting for load balancer ingress"
+	}
+	elements, err := ingress.Elements()
+	if err != nil {
+		return Unknown, err.Error()
+	}
+	if len(elements) == 0 {
+		return NotReady, "waiting for load balancer ingress"
+	}
+	return Ready, "load balancer ingress assigned"
+}
+
+func checkJobReady(node *yaml.RNode) (ReadinessStatus, string) {
+	completions := lookupInt(node, "spec", "completions")
+	if completions == 0 {
+		completions = 1
+	}
+	if succeeded := lookupInt(node, "status", "succeeded"); succeeded < completions {
+		return NotReady, fmt.Sprintf("%d/%d completions", succeeded, completions)
+	}
+	return Ready, "job completed"
+}
+
+func checkCRDReady(node *yaml.RNode) (ReadinessStatus, string) {
+	conditions, err := node.Pipe(yaml.Lookup("status", "conditions"))
+	if err != nil {
+		return Unknown, err.Error()
+	}
+	if conditions == nil {
+		return NotReady, "no status conditions reported"
+	}
+	elements, err := conditions.Elements()
+	if err != nil {
+		return Unknown, err.Error()
+	}
+	for _, c := range elements {
+		if lookupString(c, "type") == "Established" {
+			if lookupString(c, "status") == "True" {
+				return Ready, "CustomResourceDefinition established"
+			}
+			return NotReady, "CustomResourceDefinition not yet established"
+		}
+	}
+	return NotReady, "Established condition not reported"
+}
+
+func lookupString(node *yaml.RNode, path ...string) string {
+	n, err := node.Pipe(yaml.Lookup(path...))
+	if err != nil || n == nil {
+		return ""
+	}
+	return strings.TrimSpace(n.YNode().Value)
+}
+
+// defaultReplicas applies Kubernetes' default of 1 for a workload's
+// spec.replicas when the field is unset, the same way checkJobReady
+// defaults a missing spec.completions.
+func defaultReplicas(node *yaml.RNode) int {
+	if lookupString(node, "spec", "replicas") == "" {
+		return 1
+	}
+	return lookupInt(node, "spec", "replicas")
+}
+
+func lookupInt(node *yaml.RNode, path ...string) int {
+	v, err := strconv.Atoi(lookupString(node, path...))
+	if err != nil {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,412 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestCheckDeploymentReady_DefaultsMissingReplicas guards against treating a
+// freshly-created Deployment with no spec.replicas (and so no
+// status.readyReplicas either) as Ready: Kubernetes defaults an unset
+// spec.replicas to 1, so 0 ready out of a default of 1 must be NotReady.
+func TestCheckDeploymentReady_DefaultsMissingReplicas(t *testing.T) {
+	node := mustParse(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  generation: 1
+status:
+  observedGeneration: 1
+`)
+	status, _ := checkDeploymentReady(node)
+	if status != NotReady {
+		t.Errorf("expected NotReady for a Deployment with no replicas set and none ready, got %v", status)
+	}
+}
+
+func TestCheckStatefulSetReady_DefaultsMissingReplicas(t *testing.T) {
+	node := mustParse(t, `
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: foo
+`)
+	status, _ := checkStatefulSetReady(node)
+	if status != NotReady {
+		t.Errorf("expected NotReady for a StatefulSet with no replicas set and none ready, got %v", status)
+	}
+}
+
+func TestCheckReplicaSetReady_DefaultsMissingReplicas(t *testing.T) {
+	node := mustParse(t, `
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: foo
+`)
+	status, _ := checkReplicaSetReady(node)
+	if status != NotReady {
+		t.Errorf("expected NotReady for a ReplicaSet with no replicas set and none ready, got %v", status)
+	}
+}
+
+func TestCheckDeploymentReady_ExplicitReplicasSatisfied(t *testing.T) {
+	node := mustParse(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  generation: 1
+spec:
+  replicas: 3
+status:
+  observedGeneration: 1
+  readyReplicas: 3
+`)
+	status, _ := checkDeploymentReady(node)
+	if status != Ready {
+		t.Errorf("expected Ready once readyReplicas matches spec.replicas, got %v", status)
+	}
+}
+
+// TestNewReadinessValidator_UnregisteredKindIsNotAnError guards against a
+// realistic ResourceList (containing e.g. a ConfigMap with no registered
+// readiness check) making Command report an error: ReadinessCheck emits an
+// Info item for kinds it doesn't understand, and Command must not treat
+// that as a failure the way it does a Warning or Error item.
+func TestNewReadinessValidator_UnregisteredKindIsNotAnError(t *testing.T) {
+	cmd := Command(nil, NewReadinessValidator())
+	cmd.SetIn(bytes.NewBufferString(`
+apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: foo
+`))
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+
+	if err := cmd.Execute(); err != nil {
+		t.Errorf("expected no error for an Info-only result, got %v", err)
+	}
+}
+
+func TestCheckDaemonSetReady(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want ReadinessStatus
+	}{
+		{"not all scheduled pods ready", `
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: foo
+status:
+  desiredNumberScheduled: 3
+  numberReady: 1
+`, NotReady},
+		{"all scheduled pods ready", `
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: foo
+status:
+  desiredNumberScheduled: 3
+  numberReady: 3
+`, Ready},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := checkDaemonSetReady(mustParse(t, tc.yaml))
+			if status != tc.want {
+				t.Errorf("checkDaemonSetReady() = %v, want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPodReady(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want ReadinessStatus
+	}{
+		{"succeeded", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Succeeded
+`, Ready},
+		{"pending", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Pending
+`, NotReady},
+		{"running with Ready condition True", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Running
+  conditions:
+  - type: Ready
+    status: "True"
+`, Ready},
+		{"running with Ready condition False", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Running
+  conditions:
+  - type: Ready
+    status: "False"
+`, NotReady},
+		{"running with no conditions", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Running
+`, Unknown},
+		{"running with no Ready condition", `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: foo
+status:
+  phase: Running
+  conditions:
+  - type: Initialized
+    status: "True"
+`, Unknown},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := checkPodReady(mustParse(t, tc.yaml))
+			if status != tc.want {
+				t.Errorf("checkPodReady() = %v, want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckPVCReady(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want ReadinessStatus
+	}{
+		{"pending", `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: foo
+status:
+  phase: Pending
+`, NotReady},
+		{"bound", `
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: foo
+status:
+  phase: Bound
+`, Ready},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := checkPVCReady(mustParse(t, tc.yaml))
+			if status != tc.want {
+				t.Errorf("checkPVCReady() = %v, want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckServiceReady(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want ReadinessStatus
+	}{
+		{"ClusterIP does not require a load balancer", `
+apiVersion: v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  type: ClusterIP
+`, Ready},
+		{"LoadBalancer with no ingress reported", `
+apiVersion: v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  type: LoadBalancer
+`, NotReady},
+		{"LoadBalancer with empty ingress list", `
+apiVersion: v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  type: LoadBalancer
+status:
+  loadBalancer:
+    ingress: []
+`, NotReady},
+		{"LoadBalancer with ingress assigned", `
+apiVersion: v1
+kind: Service
+metadata:
+  name: foo
+spec:
+  type: LoadBalancer
+status:
+  loadBalancer:
+    ingress:
+    - ip: 1.2.3.4
+`, Ready},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := checkServiceReady(mustParse(t, tc.yaml))
+			if status != tc.want {
+				t.Errorf("checkServiceReady() = %v, want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckJobReady(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want ReadinessStatus
+	}{
+		{"defaults missing spec.completions to 1", `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: foo
+`, NotReady},
+		{"completions satisfied", `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: foo
+spec:
+  completions: 3
+status:
+  succeeded: 3
+`, Ready},
+		{"completions not yet satisfied", `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: foo
+spec:
+  completions: 3
+status:
+  succeeded: 1
+`, NotReady},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := checkJobReady(mustParse(t, tc.yaml))
+			if status != tc.want {
+				t.Errorf("checkJobReady() = %v, want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckCRDReady(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		want ReadinessStatus
+	}{
+		{"no status conditions reported", `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foo
+`, NotReady},
+		{"Established condition not reported", `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foo
+status:
+  conditions:
+  - type: NamesAccepted
+    status: "True"
+`, NotReady},
+		{"Established condition False", `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foo
+status:
+  conditions:
+  - type: Established
+    status: "False"
+`, NotReady},
+		{"Established condition True", `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: foo
+status:
+  conditions:
+  - type: Established
+    status: "True"
+`, Ready},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, _ := checkCRDReady(mustParse(t, tc.yaml))
+			if status != tc.want {
+				t.Errorf("checkCRDReady() = %v, want %v", status, tc.want)
+			}
+		})
+	}
+}
+
+func TestResult_HasFailure(t *testing.T) {
+	cases := []struct {
+		name string
+		r    Result
+		want bool
+	}{
+		{"empty", Result{}, false},
+		{"info only", Result{Items: []Item{{Severity: Info}}}, false},
+		{"warning", Result{Items: []Item{{Severity: Info}, {Severity: Warning}}}, true},
+		{"error", Result{Items: []Item{{Severity: Error}}}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.r.HasFailure(); got != tc.want {
+				t.Errorf("HasFailure() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Severity indicates how serious a Result Item is.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+	// Fix marks an Item whose Patch can be applied automatically, e.g. by
+	// AutofixCommand, without a human deciding whether the change is safe.
+	Fix Severity = "fix"
+)
+
+// PatchType identifies the encoding of a Patch.
+type PatchType string
+
+const (
+	// JSONPatchType is an RFC 6902 JSON Patch.
+	JSONPatchType PatchType = "json-patch"
+	// JSONMergePatchType is an RFC 7396 JSON Merge Patch.
+	JSONMergePatchType PatchType = "merge-patch"
+)
+
+// Patch is a machine-applicable fix for the resource an Item's ResourceRef
+// identifies.
+type Patch struct {
+	// Type selects how Patch is interpreted.
+	Type PatchType `yaml:"type,omitempty"`
+	// Patch is the JSON-encoded patch document itself.
+	Patch string `yaml:"patch,omitempty"`
+}
+
+// Field describes the part of a resource that a Result Item applies to.
+type Field struct {
+	// Path is the field path, e.g. "spec.replicas".
+	Path string `yaml:"path,omitempty"`
+	// CurrentValue is the value currently set at Path, if any.
+	CurrentValue string `yaml:"currentValue,omitempty"`
+	// SuggestedValue is the value the function recommends for Path.
+	SuggestedValue string `yaml:"suggestedValue,omitempty"`
+}
+
+// Item is a single observation about a resource, e.g. a validation error.
+type Item struct {
+	// Message is a human readable description of the observation.
+	Message string `yaml:"message,omitempty"`
+	// Severity classifies how serious the observation is.
+	Severity Severity `yaml:"severity,omitempty"`
+	// ResourceRef identifies the resource the Item refers to.
+	ResourceRef yaml.ResourceMeta `yaml:"resourceRef,omitempty"`
+	// Field identifies the part of the resource the Item refers to.
+	Field Field `yaml:"field,omitempty"`
+	// Patch, if set, is a machine-applicable fix for this Item. See
+	// AutofixCommand.
+	Patch *Patch `yaml:"patch,omitempty"`
+}
+
+// Result is a named collection of Items returned by a function. Result
+// implements error so it can be returned directly from a
+// ResourceListProcessor to populate ResourceList.results.
+type Result struct {
+	// Name identifies the function or check that produced the Items.
+	Name string `yaml:"name,omitempty"`
+	// Items are the individual observations that make up the Result.
+	Items []Item `yaml:"items,omitempty"`
+}
+
+func (r Result) Error() string {
+	return fmt.Sprintf("%s: %d result item(s)", r.Name, len(r.Items))
+}
+
+// HasFailure reports whether r contains an Item serious enough that Command
+// should report it as a command error, i.e. anything other than Info --
+// an Info item, like "no readiness check registered for this kind", is
+// purely informational and shouldn't fail the run on its own.
+func (r Result) HasFailure() bool {
+	for _, it := range r.Items {
+		if it.Severity != Info {
+			return true
+		}
+	}
+	return false
+}
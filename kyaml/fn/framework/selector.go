@@ -0,0 +1,304 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SelectorOperator is the comparison a SelectorRequirement performs, modeled
+// after Kubernetes set-based label selectors.
+type SelectorOperator string
+
+const (
+	SelectorOpIn           SelectorOperator = "In"
+	SelectorOpNotIn        SelectorOperator = "NotIn"
+	SelectorOpExists       SelectorOperator = "Exists"
+	SelectorOpDoesNotExist SelectorOperator = "DoesNotExist"
+)
+
+// SelectorRequirement is a single set-based match expression, e.g.
+// `tier in (frontend,backend)` or `!canary`.
+type SelectorRequirement struct {
+	// Key is the label or annotation name being matched.
+	Key string `yaml:"key"`
+	// Operator is the comparison to perform.
+	Operator SelectorOperator `yaml:"operator"`
+	// Values are the comparison values; only used by In and NotIn.
+	Values []string `yaml:"values,omitempty"`
+	// Annotation selects annotations instead of labels when true. Building
+	// an annotation-based requirement by hand means setting this field
+	// directly; ParseSelectorExpression(expr, true) sets it for you.
+	Annotation bool `yaml:"annotation,omitempty"`
+}
+
+// Selector matches a subset of ResourceList items, combining the filter
+// styles the Docker CLI accepts for `docker stack services` (apiVersion,
+// kind, name, namespace) with Kubernetes-style label/annotation selectors.
+// All non-zero fields on a Selector must match (logical AND); use AnyOf to
+// union several Selectors (logical OR).
+type Selector struct {
+	APIVersion string `yaml:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty"`
+	Name       string `yaml:"name,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty"`
+
+	// MatchLabels is a map of label key/value pairs that must all be present.
+	MatchLabels map[string]string `yaml:"matchLabels,omitempty"`
+	// MatchAnnotations is a map of annotation key/value pairs that must all
+	// be present.
+	MatchAnnotations map[string]string `yaml:"matchAnnotations,omitempty"`
+	// MatchExpressions are set-based label requirements, ANDed together and
+	// with MatchLabels/MatchAnnotations.
+	MatchExpressions []SelectorRequirement `yaml:"matchExpressions,omitempty"`
+}
+
+// Matcher splits a slice of items into the subset it matches and the
+// remainder. Selector and Set both implement Matcher.
+type Matcher interface {
+	Select(items []*yaml.RNode) (matched, unmatched []*yaml.RNode, err error)
+}
+
+// Set is a union ("OR") of Selectors: an item matches the Set if it matches
+// at least one member Selector.
+type Set []Selector
+
+// AnyOf returns a Set that matches an item matched by any of sel.
+func AnyOf(sel ...Selector) Set {
+	return Set(sel)
+}
+
+// MatchAll merges sel into a single Selector that requires every non-zero
+// field across all of them, e.g. MatchAll(byKind, byLabel) lets a generator
+// narrow "Deployments" further down to "Deployments I previously generated"
+// without hand-rolling the intersection. Where two selectors set the same
+// field, or the same label or annotation key, to different values, the
+// later selector's value replaces the earlier one.
+func MatchAll(sel ...Selector) Selector {
+	var out Selector
+	for _, s := range sel {
+		if s.APIVersion != "" {
+			out.APIVersion = s.APIVersion
+		}
+		if s.Kind != "" {
+			out.Kind = s.Kind
+		}
+		if s.Name != "" {
+			out.Name = s.Name
+		}
+		if s.Namespace != "" {
+			out.Namespace = s.Namespace
+		}
+		for k, v := range s.MatchLabels {
+			if out.MatchLabels == nil {
+				out.MatchLabels = map[string]string{}
+			}
+			out.MatchLabels[k] = v
+		}
+		for k, v := range s.MatchAnnotations {
+			if out.MatchAnnotations == nil {
+				out.MatchAnnotations = map[string]string{}
+			}
+			out.MatchAnnotations[k] = v
+		}
+		out.MatchExpressions = append(out.MatchExpressions, s.MatchExpressions...)
+	}
+	return out
+}
+
+// Select splits items into the subset matched by sel and the remainder,
+// preserving the relative order of each subset.
+func (sel Selector) Select(items []*yaml.RNode) (matched, unmatched []*yaml.RNode, err error) {
+	for _, item := range items {
+		ok, err := sel.Matches(item)
+		if err != nil {
+			return nil, nil, errors.Wrap(err)
+		}
+		if ok {
+			matched = append(matched, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	}
+	return matched, unmatched, nil
+}
+
+// Select splits items into the subset matched by any Selector in set and
+// the remainder, preserving the relative order of each subset.
+func (set Set) Select(items []*yaml.RNode) (matched, unmatched []*yaml.RNode, err error) {
+	for _, item := range items {
+		ok, err := set.Matches(item)
+		if err != nil {
+			return nil, nil, errors.Wrap(err)
+		}
+		if ok {
+			matched = append(matched, item)
+		} else {
+			unmatched = append(unmatched, item)
+		}
+	}
+	return matched, unmatched, nil
+}
+
+// Matches reports whether item satisfies every field set on sel.
+func (sel Selector) Matches(item *yaml.RNode) (bool, error) {
+	meta, err := item.GetMeta()
+	if err != nil {
+		return false, errors.Wrap(err)
+	}
+	if sel.APIVersion != "" && sel.APIVersion != meta.APIVersion {
+		return false, nil
+	}
+	if sel.Kind != "" && sel.Kind != meta.Kind {
+		return false, nil
+	}
+	if sel.Name != "" && sel.Name != meta.Name {
+		return false, nil
+	}
+	if sel.Namespace != "" && sel.Namespace != meta.Namespace {
+		return false, nil
+	}
+	for k, v := range sel.MatchLabels {
+		if meta.Labels[k] != v {
+			return false, nil
+		}
+	}
+	for k, v := range sel.MatchAnnotations {
+		if meta.Annotations[k] != v {
+			return false, nil
+		}
+	}
+	for _, req := range sel.MatchExpressions {
+		ok, err := req.matches(meta)
+		if err != nil {
+			return false, errors.Wrap(err)
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Matches reports whether item satisfies at least one Selector in set. An
+// empty Set matches everything, mirroring an unset Selector.
+func (set Set) Matches(item *yaml.RNode) (bool, error) {
+	if len(set) == 0 {
+		return true, nil
+	}
+	for _, sel := range set {
+		ok, err := sel.Matches(item)
+		if err != nil {
+			return false, errors.Wrap(err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (req SelectorRequirement) matches(meta yaml.ResourceMeta) (bool, error) {
+	values := meta.Labels
+	if req.Annotation {
+		values = meta.Annotations
+	}
+	v, ok := values[req.Key]
+	switch req.Operator {
+	case SelectorOpExists:
+		return ok, nil
+	case SelectorOpDoesNotExist:
+		return !ok, nil
+	case SelectorOpIn:
+		return ok && contains(req.Values, v), nil
+	case SelectorOpNotIn:
+		return !ok || !contains(req.Values, v), nil
+	default:
+		return false, errors.Errorf("unsupported selector operator %q", req.Operator)
+	}
+}
+
+func contains(values []string, v string) bool {
+	for _, want := range values {
+		if want == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseSelectorExpression parses a single set-based label expression in the
+// form used by `kubectl --selector`, e.g. "tier in (frontend,backend)",
+// "tier notin (cache)", "release", or "!canary". Pass annotation=true to
+// match against annotations instead of labels. The "in"/"notin" operators
+// are matched as standalone whitespace-delimited tokens, not substrings, so
+// keys like "domain" or "plugin" aren't mistaken for the "in" operator.
+func ParseSelectorExpression(expr string, annotation bool) (SelectorRequirement, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "!") {
+		return SelectorRequirement{Key: strings.TrimSpace(expr[1:]), Operator: SelectorOpDoesNotExist, Annotation: annotation}, nil
+	}
+
+	fields := strings.Fields(expr)
+	for i, f := range fields {
+		switch f {
+		case "notin":
+			key, values, err := splitKeyValues(fields, i)
+			return SelectorRequirement{Key: key, Operator: SelectorOpNotIn, Values: values, Annotation: annotation}, err
+		case "in":
+			key, values, err := splitKeyValues(fields, i)
+			return SelectorRequirement{Key: key, Operator: SelectorOpIn, Values: values, Annotation: annotation}, err
+		}
+	}
+	return SelectorRequirement{Key: expr, Operator: SelectorOpExists, Annotation: annotation}, nil
+}
+
+// splitKeyValues splits fields around its operator token at index opIdx
+// into a key and its parenthesized, comma-separated values.
+func splitKeyValues(fields []string, opIdx int) (string, []string, error) {
+	if opIdx == 0 || opIdx == len(fields)-1 {
+		return "", nil, errors.Errorf("invalid selector expression %q", strings.Join(fields, " "))
+	}
+	key := strings.Join(fields[:opIdx], " ")
+	raw := strings.Join(fields[opIdx+1:], " ")
+	raw = strings.TrimPrefix(raw, "(")
+	raw = strings.TrimSuffix(raw, ")")
+	var values []string
+	for _, v := range strings.Split(raw, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return key, values, nil
+}
+
+// selectorFromFunctionConfig reads a Set from the well-known
+// spec.targets field of functionConfig, if present, so pipeline authors can
+// reuse a single function binary against different subsets without
+// recompiling Selector values into it.
+func selectorFromFunctionConfig(functionConfig *yaml.RNode) (Matcher, error) {
+	if functionConfig == nil {
+		return nil, nil
+	}
+	targets, err := functionConfig.Pipe(yaml.Lookup("spec", "targets"))
+	if err != nil || targets == nil {
+		return nil, err
+	}
+	elements, err := targets.Elements()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	set := make(Set, 0, len(elements))
+	for _, el := range elements {
+		var sel Selector
+		if err := yaml.Unmarshal([]byte(el.MustString()), &sel); err != nil {
+			return nil, errors.Wrap(err)
+		}
+		set = append(set, sel)
+	}
+	return set, nil
+}
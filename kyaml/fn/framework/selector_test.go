@@ -0,0 +1,201 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"testing"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// podWithLabelsAndAnnotations builds a Pod with the given metadata.labels
+// and metadata.annotations set directly in YAML, to keep these tests
+// independent of any particular field-setter API.
+func podWithLabelsAndAnnotations(t *testing.T, labels, annotations map[string]string) *yaml.RNode {
+	t.Helper()
+	m := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name": "foo",
+		},
+	}
+	meta := m["metadata"].(map[string]interface{})
+	if len(labels) > 0 {
+		l := map[string]interface{}{}
+		for k, v := range labels {
+			l[k] = v
+		}
+		meta["labels"] = l
+	}
+	if len(annotations) > 0 {
+		a := map[string]interface{}{}
+		for k, v := range annotations {
+			a[k] = v
+		}
+		meta["annotations"] = a
+	}
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		t.Fatalf("yaml.Marshal: %v", err)
+	}
+	return mustParse(t, string(b))
+}
+
+func TestSelectorRequirement_Labels(t *testing.T) {
+	item := podWithLabelsAndAnnotations(t, map[string]string{"tier": "frontend"}, nil)
+
+	cases := []struct {
+		name string
+		req  SelectorRequirement
+		want bool
+	}{
+		{"in matches", SelectorRequirement{Key: "tier", Operator: SelectorOpIn, Values: []string{"frontend", "backend"}}, true},
+		{"in no match", SelectorRequirement{Key: "tier", Operator: SelectorOpIn, Values: []string{"backend"}}, false},
+		{"notin matches absent value", SelectorRequirement{Key: "tier", Operator: SelectorOpNotIn, Values: []string{"backend"}}, true},
+		{"notin excludes present value", SelectorRequirement{Key: "tier", Operator: SelectorOpNotIn, Values: []string{"frontend"}}, false},
+		{"exists", SelectorRequirement{Key: "tier", Operator: SelectorOpExists}, true},
+		{"exists absent key", SelectorRequirement{Key: "canary", Operator: SelectorOpExists}, false},
+		{"does not exist", SelectorRequirement{Key: "canary", Operator: SelectorOpDoesNotExist}, true},
+		{"does not exist present key", SelectorRequirement{Key: "tier", Operator: SelectorOpDoesNotExist}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sel := Selector{MatchExpressions: []SelectorRequirement{tc.req}}
+			got, err := sel.Matches(item)
+			if err != nil {
+				t.Fatalf("Matches: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Matches() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestSelectorRequirement_Annotations exercises the Annotation field that
+// external callers, not just ParseSelectorExpression, can set directly on a
+// SelectorRequirement literal.
+func TestSelectorRequirement_Annotations(t *testing.T) {
+	item := podWithLabelsAndAnnotations(t, nil, map[string]string{"release": "stable"})
+
+	sel := Selector{MatchExpressions: []SelectorRequirement{
+		{Key: "release", Operator: SelectorOpIn, Values: []string{"stable"}, Annotation: true},
+	}}
+	matched, err := sel.Matches(item)
+	if err != nil {
+		t.Fatalf("Matches: %v", err)
+	}
+	if !matched {
+		t.Errorf("expected annotation-based requirement to match")
+	}
+
+	// the same Key/Operator/Values read as a label requirement should not
+	// match, since release is only set as an annotation.
+	labelSel := Selector{MatchExpressions: []SelectorRequirement{
+		{Key: "release", Operator: SelectorOpIn, Values: []string{"stable"}},
+	}}
+	matched, err = labelSel.Matches(item)
+	if err != nil {
+		t.Fatalf("Matches: %v", err)
+	}
+	if matched {
+		t.Errorf("expected label-based requirement to miss an annotation-only value")
+	}
+}
+
+func TestParseSelectorExpression(t *testing.T) {
+	cases := []struct {
+		expr string
+		want SelectorRequirement
+	}{
+		{"tier", SelectorRequirement{Key: "tier", Operator: SelectorOpExists}},
+		{"!canary", SelectorRequirement{Key: "canary", Operator: SelectorOpDoesNotExist}},
+		{"tier in (frontend,backend)", SelectorRequirement{Key: "tier", Operator: SelectorOpIn, Values: []string{"frontend", "backend"}}},
+		{"tier notin (cache)", SelectorRequirement{Key: "tier", Operator: SelectorOpNotIn, Values: []string{"cache"}}},
+		// keys that merely contain the substrings "in"/"notin" must not be
+		// mistaken for the In/NotIn operators.
+		{"domain", SelectorRequirement{Key: "domain", Operator: SelectorOpExists}},
+		{"plugin", SelectorRequirement{Key: "plugin", Operator: SelectorOpExists}},
+		{"mainline", SelectorRequirement{Key: "mainline", Operator: SelectorOpExists}},
+		{"pinned", SelectorRequirement{Key: "pinned", Operator: SelectorOpExists}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := ParseSelectorExpression(tc.expr, false)
+			if err != nil {
+				t.Fatalf("ParseSelectorExpression(%q): %v", tc.expr, err)
+			}
+			if got.Key != tc.want.Key || got.Operator != tc.want.Operator || !stringSlicesEqual(got.Values, tc.want.Values) {
+				t.Errorf("ParseSelectorExpression(%q) = %+v, want %+v", tc.expr, got, tc.want)
+			}
+		})
+	}
+
+	got, err := ParseSelectorExpression("release in (stable)", true)
+	if err != nil {
+		t.Fatalf("ParseSelectorExpression: %v", err)
+	}
+	if !got.Annotation {
+		t.Errorf("expected Annotation to be set when annotation=true is passed")
+	}
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestSelectorFromFunctionConfig covers the spec.targets path, including a
+// set-based matchExpressions entry, matching MergeGenerator-style
+// functionConfig authors would write by hand.
+func TestSelectorFromFunctionConfig(t *testing.T) {
+	functionConfig := mustParse(t, `
+apiVersion: example.com/v1
+kind: MyGenerator
+spec:
+  targets:
+  - kind: Deployment
+    matchExpressions:
+    - key: tier
+      operator: In
+      values: ["frontend"]
+  - kind: Service
+`)
+	matcher, err := selectorFromFunctionConfig(functionConfig)
+	if err != nil {
+		t.Fatalf("selectorFromFunctionConfig: %v", err)
+	}
+	set, ok := matcher.(Set)
+	if !ok {
+		t.Fatalf("expected a Set, got %T", matcher)
+	}
+	if len(set) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(set))
+	}
+
+	frontendDeployment := podWithLabelsAndAnnotations(t, map[string]string{"tier": "frontend"}, nil)
+	frontendDeployment = withData(t, frontendDeployment, []string{"kind"}, "Deployment")
+	backendDeployment := podWithLabelsAndAnnotations(t, map[string]string{"tier": "backend"}, nil)
+	backendDeployment = withData(t, backendDeployment, []string{"kind"}, "Deployment")
+	service := withData(t, mustParse(t, "apiVersion: v1\nkind: Service\nmetadata:\n  name: foo\n"), []string{"kind"}, "Service")
+
+	matched, unmatched, err := set.Select([]*yaml.RNode{frontendDeployment, backendDeployment, service})
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(matched) != 2 {
+		t.Fatalf("expected frontend Deployment and Service to match, got %d matched", len(matched))
+	}
+	if len(unmatched) != 1 {
+		t.Fatalf("expected backend Deployment to be unmatched, got %d unmatched", len(unmatched))
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"bytes"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// generatedByAnnotation records which function and template produced a
+// resource, so later runs can find and reconcile it.
+const generatedByAnnotation = "config.kubernetes.io/generated-by"
+
+// TemplateOptions configures how TemplateCommand renders templates.
+type TemplateOptions struct {
+	// MissingKey controls template behavior when a map is indexed with an
+	// absent key: "error" (default), "zero", or "invalid". It is passed
+	// straight through to text/template's Option("missingkey=...").
+	MissingKey string
+	// DefaultNamespace is injected into the metadata.namespace of any
+	// rendered resource that doesn't set its own.
+	DefaultNamespace string
+}
+
+// TemplateCommand returns a cobra.Command that renders every file in
+// templates as a Go text/template against functionConfig, with sprig's
+// helper functions available, and merges the result into
+// ResourceList.items. Rendered output is split on `---` document
+// separators and parsed with yaml.Parse. Every emitted resource is stamped
+// with a config.kubernetes.io/generated-by annotation of "<fnName>/<file>";
+// on subsequent runs, a previous item carrying that annotation is replaced
+// in place by its freshly rendered copy, and dropped if the templates no
+// longer emit it. The replacement is wholesale: manual edits to a
+// previously generated item are not kept.
+func TemplateCommand(fnName string, functionConfig interface{}, templates fs.FS, opts TemplateOptions) *cobra.Command {
+	return Command(functionConfig, func(items []*yaml.RNode) ([]*yaml.RNode, error) {
+		rendered, err := renderTemplates(fnName, templates, functionConfig, opts)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		return reconcileGenerated(fnName, items, rendered)
+	})
+}
+
+func renderTemplates(fnName string, templates fs.FS, data interface{}, opts TemplateOptions) ([]*yaml.RNode, error) {
+	var out []*yaml.RNode
+	err := fs.WalkDir(templates, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		b, err := fs.ReadFile(templates, p)
+		if err != nil {
+			return err
+		}
+		tmpl := template.New(path.Base(p)).Funcs(sprig.TxtFuncMap())
+		missingKey := opts.MissingKey
+		if missingKey == "" {
+			missingKey = "error"
+		}
+		tmpl = tmpl.Option("missingkey=" + missingKey)
+		if tmpl, err = tmpl.Parse(string(b)); err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return err
+		}
+
+		for _, doc := range strings.Split(buf.String(), "\n---\n") {
+			doc = strings.TrimSpace(doc)
+			if doc == "" {
+				continue
+			}
+			node, err := yaml.Parse(doc)
+			if err != nil {
+				return err
+			}
+			if err := node.PipeE(yaml.SetAnnotation(generatedByAnnotation, fnName+"/"+path.Base(p))); err != nil {
+				return err
+			}
+			if opts.DefaultNamespace != "" {
+				if err := setDefaultNamespace(node, opts.DefaultNamespace); err != nil {
+					return err
+				}
+			}
+			out = append(out, node)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func setDefaultNamespace(node *yaml.RNode, namespace string) error {
+	existing, err := node.Pipe(yaml.Lookup("metadata", "namespace"))
+	if err != nil || existing != nil {
+		return err
+	}
+	return node.PipeE(yaml.LookupCreate(yaml.ScalarNode, "metadata", "namespace"), yaml.FieldSetter{StringValue: namespace})
+}
+
+// reconcileGenerated merges rendered into existing: items previously
+// generated by fnName (identified via generatedByAnnotation) are replaced
+// by their freshly rendered copy, or dropped if no longer rendered;
+// everything else, including newly rendered items with no prior copy,
+// passes through unchanged.
+func reconcileGenerated(fnName string, existing, rendered []*yaml.RNode) ([]*yaml.RNode, error) {
+	byKey := map[string]*yaml.RNode{}
+	for _, r := range rendered {
+		key, err := generatedKey(r)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		byKey[key] = r
+	}
+
+	seen := map[string]bool{}
+	var out []*yaml.RNode
+	for _, item := range existing {
+		meta, err := item.GetMeta()
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if !strings.HasPrefix(meta.Annotations[generatedByAnnotation], fnName+"/") {
+			out = append(out, item)
+			continue
+		}
+
+		key, err := generatedKey(item)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if r, ok := byKey[key]; ok {
+			out = append(out, r)
+			seen[key] = true
+		}
+		// else: we previously generated this resource and no longer do -- drop it
+	}
+
+	for _, r := range rendered {
+		key, err := generatedKey(r)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if !seen[key] {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func generatedKey(node *yaml.RNode) (string, error) {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return "", err
+	}
+	return strings.Join([]string{meta.APIVersion, meta.Kind, meta.Namespace, meta.Name}, "/"), nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// TestReconcileGenerated covers the three outcomes reconcileGenerated can
+// produce for an existing item: replaced by its freshly rendered copy,
+// dropped because the templates no longer emit it, or passed through
+// because it was never generated by fnName at all.
+func TestReconcileGenerated(t *testing.T) {
+	const fnName = "my-fn"
+
+	kept := withData(t, mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+`), []string{"metadata", "annotations", generatedByAnnotation}, fnName+"/kept.yaml")
+
+	dropped := withData(t, mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: dropped
+`), []string{"metadata", "annotations", generatedByAnnotation}, fnName+"/dropped.yaml")
+
+	userOwned := mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: user-owned
+`)
+
+	renderedKept := withData(t, mustParse(t, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kept
+data:
+  a: "2"
+`), []string{"metadata", "annotations", generatedByAnnotation}, fnName+"/kept.yaml")
+
+	out, err := reconcileGenerated(fnName, []*yaml.RNode{kept, dropped, userOwned}, []*yaml.RNode{renderedKept})
+	if err != nil {
+		t.Fatalf("reconcileGenerated: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 items (kept, user-owned), got %d", len(out))
+	}
+
+	byName := map[string]*yaml.RNode{}
+	for _, item := range out {
+		meta, err := item.GetMeta()
+		if err != nil {
+			t.Fatalf("GetMeta: %v", err)
+		}
+		byName[meta.Name] = item
+	}
+	if _, ok := byName["dropped"]; ok {
+		t.Errorf("expected previously-generated 'dropped' to be dropped when no longer rendered")
+	}
+	if _, ok := byName["user-owned"]; !ok {
+		t.Errorf("expected unrelated 'user-owned' item to pass through untouched")
+	}
+
+	keptOut, ok := byName["kept"]
+	if !ok {
+		t.Fatalf("expected 'kept' to be present")
+	}
+	m, err := toMap(keptOut)
+	if err != nil {
+		t.Fatalf("toMap: %v", err)
+	}
+	data, _ := m["data"].(map[string]interface{})
+	if data["a"] != "2" {
+		t.Errorf("expected 'kept' to be replaced by its freshly rendered copy, got %v", m)
+	}
+}
+
+func TestSetDefaultNamespace(t *testing.T) {
+	withoutNamespace := mustParse(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")
+	if err := setDefaultNamespace(withoutNamespace, "team-a"); err != nil {
+		t.Fatalf("setDefaultNamespace: %v", err)
+	}
+	meta, err := withoutNamespace.GetMeta()
+	if err != nil {
+		t.Fatalf("GetMeta: %v", err)
+	}
+	if meta.Namespace != "team-a" {
+		t.Errorf("expected default namespace to be injected, got %q", meta.Namespace)
+	}
+
+	withNamespace := mustParse(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n  namespace: team-b\n")
+	if err := setDefaultNamespace(withNamespace, "team-a"); err != nil {
+		t.Fatalf("setDefaultNamespace: %v", err)
+	}
+	meta, err = withNamespace.GetMeta()
+	if err != nil {
+		t.Fatalf("GetMeta: %v", err)
+	}
+	if meta.Namespace != "team-b" {
+		t.Errorf("expected an already-set namespace to be left alone, got %q", meta.Namespace)
+	}
+}
+
+// TestRenderTemplates covers the "\n---\n" multi-document split, the
+// generatedByAnnotation stamp, and TemplateOptions.DefaultNamespace
+// injection.
+func TestRenderTemplates(t *testing.T) {
+	const fnName = "my-fn"
+	templates := fstest.MapFS{
+		"configmaps.yaml": &fstest.MapFile{Data: []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}-a
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Name }}-b
+`)},
+	}
+	data := struct{ Name string }{Name: "foo"}
+
+	out, err := renderTemplates(fnName, templates, data, TemplateOptions{DefaultNamespace: "team-a"})
+	if err != nil {
+		t.Fatalf("renderTemplates: %v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 rendered documents, got %d", len(out))
+	}
+
+	names := map[string]bool{}
+	for _, node := range out {
+		meta, err := node.GetMeta()
+		if err != nil {
+			t.Fatalf("GetMeta: %v", err)
+		}
+		names[meta.Name] = true
+		if meta.Annotations[generatedByAnnotation] != fnName+"/configmaps.yaml" {
+			t.Errorf("expected generated-by annotation %q, got %v", fnName+"/configmaps.yaml", meta.Annotations)
+		}
+		if meta.Namespace != "team-a" {
+			t.Errorf("expected default namespace to be injected, got %q", meta.Namespace)
+		}
+	}
+	if !names["foo-a"] || !names["foo-b"] {
+		t.Errorf("expected both foo-a and foo-b to be rendered, got %v", names)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// WaitCommand returns a cobra.Command that polls read every poll interval,
+// running ReadinessCheck over the returned items, until every item is ready
+// or timeout elapses. read is supplied by the caller so the same readiness
+// logic can drive both an offline KRM validator (reading ResourceList.items
+// once) and a `helm wait`-style gate that re-reads live cluster state on
+// each poll.
+func WaitCommand(read func() ([]*yaml.RNode, error), timeout, poll time.Duration) *cobra.Command {
+	return &cobra.Command{
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			deadline := time.Now().Add(timeout)
+			for {
+				items, err := read()
+				if err != nil {
+					return errors.Wrap(err)
+				}
+
+				result := Result{Name: "readiness-wait"}
+				allReady := true
+				for _, item := range items {
+					it, err := ReadinessCheck(item)
+					if err != nil {
+						return errors.Wrap(err)
+					}
+					if it.Severity != Info {
+						allReady = false
+					}
+					result.Items = append(result.Items, it)
+				}
+				if allReady {
+					return nil
+				}
+				if time.Now().After(deadline) {
+					return result
+				}
+				time.Sleep(poll)
+			}
+		},
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright 2019 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package framework
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// TestWaitCommand_AllReadyReturnsImmediately guards against WaitCommand
+// sleeping or polling more than once when the first read already has every
+// item ready.
+func TestWaitCommand_AllReadyReturnsImmediately(t *testing.T) {
+	reads := 0
+	read := func() ([]*yaml.RNode, error) {
+		reads++
+		return []*yaml.RNode{mustParse(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  generation: 1
+spec:
+  replicas: 1
+status:
+  observedGeneration: 1
+  readyReplicas: 1
+`)}, nil
+	}
+
+	cmd := WaitCommand(read, time.Second, time.Hour)
+	start := time.Now()
+	if err := cmd.RunE(cmd, nil); err != nil {
+		t.Errorf("expected no error once all items are ready, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Hour {
+		t.Errorf("expected to return before ever sleeping, took %v", elapsed)
+	}
+	if reads != 1 {
+		t.Errorf("expected exactly 1 read when the first poll is already ready, got %d", reads)
+	}
+}
+
+// TestWaitCommand_TimeoutReturnsLastResult guards against WaitCommand
+// returning a generic error instead of the last observed Result once the
+// deadline passes without every item becoming ready.
+func TestWaitCommand_TimeoutReturnsLastResult(t *testing.T) {
+	read := func() ([]*yaml.RNode, error) {
+		return []*yaml.RNode{mustParse(t, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: foo
+  generation: 1
+spec:
+  replicas: 3
+status:
+  observedGeneration: 1
+  readyReplicas: 1
+`)}, nil
+	}
+
+	cmd := WaitCommand(read, 10*time.Millisecond, time.Millisecond)
+	err := cmd.RunE(cmd, nil)
+	result, ok := err.(Result)
+	if !ok {
+		t.Fatalf("expected WaitCommand to return a Result once the deadline elapses, got %T: %v", err, err)
+	}
+	if len(result.Items) != 1 || result.Items[0].Severity != Warning {
+		t.Errorf("expected the returned Result to carry the last NotReady item, got %+v", result)
+	}
+}
+
+// TestWaitCommand_ReadErrorPropagates guards against WaitCommand swallowing
+// an error from the caller-supplied read function.
+func TestWaitCommand_ReadErrorPropagates(t *testing.T) {
+	wantErr := errors.New("boom")
+	read := func() ([]*yaml.RNode, error) {
+		return nil, wantErr
+	}
+
+	cmd := WaitCommand(read, time.Second, time.Millisecond)
+	err := cmd.RunE(cmd, nil)
+	if err == nil || !strings.Contains(err.Error(), wantErr.Error()) {
+		t.Errorf("expected the read error to propagate out of RunE, got %v", err)
+	}
+}